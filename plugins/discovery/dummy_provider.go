@@ -0,0 +1,34 @@
+package discovery
+
+import "context"
+
+// DummyProvider serves a fixed, in-memory set of signatures. It never
+// changes after startup, which makes it useful for tests and CI where
+// pulling real signature files or hitting a remote endpoint isn't wanted.
+type DummyProvider struct {
+	signatures []*DiscoverySignatures
+}
+
+// NewDummyProvider creates a provider that always serves signatures as-is.
+func NewDummyProvider(signatures []*DiscoverySignatures) *DummyProvider {
+	return &DummyProvider{signatures: signatures}
+}
+
+// Name implements Provider.
+func (p *DummyProvider) Name() string {
+	return "dummy"
+}
+
+// Run implements Provider. It sends the static set once and closes the
+// channel when ctx is done.
+func (p *DummyProvider) Run(ctx context.Context) <-chan []*DiscoverySignatures {
+	out := make(chan []*DiscoverySignatures, 1)
+	out <- p.signatures
+
+	go func() {
+		<-ctx.Done()
+		close(out)
+	}()
+
+	return out
+}
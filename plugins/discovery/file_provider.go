@@ -0,0 +1,220 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileReloadDebounce bounds how often a burst of filesystem events (e.g. an
+// editor doing write-then-rename) triggers a reload.
+const fileReloadDebounce = 250 * time.Millisecond
+
+// FileProvider reads signature bundles from a fixed set of files on disk and
+// watches the directory enclosing each file, reloading on any event (create,
+// write, rename, remove or chmod) for one of those files, so that changes are
+// picked up without an agent restart.
+type FileProvider struct {
+	files   []string
+	initial []*DiscoverySignatures
+}
+
+// NewFileProvider loads the given signature files once, synchronously, so a
+// missing file or invalid JSON fails agent startup the same way it always
+// has instead of surfacing only once Run is consumed. Run then re-sends this
+// initial set immediately and watches for subsequent changes.
+func NewFileProvider(files []string) (*FileProvider, error) {
+	p := &FileProvider{files: files}
+
+	initial, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	p.initial = initial
+
+	return p, nil
+}
+
+// Name implements Provider.
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+// Run implements Provider. It sends the already-validated initial set
+// immediately, then re-loads and re-sends the full set whenever any file
+// changes. A failed reload is logged and the previous good set keeps being
+// served; a failure to start the watcher itself is also logged, leaving the
+// initial set as the only thing ever published.
+func (p *FileProvider) Run(ctx context.Context) <-chan []*DiscoverySignatures {
+	out := make(chan []*DiscoverySignatures, 1)
+	out <- p.initial
+
+	watcher, err := newFileWatcher(p.files)
+	if err != nil {
+		log.Printf("error watching service discovery signature files: %v", err)
+		close(out)
+		return out
+	}
+
+	go func() {
+		defer close(out)
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-watcher.changed:
+				if !ok {
+					return
+				}
+				signatures, err := p.load()
+				if err != nil {
+					log.Printf("error reloading service discovery signatures: %v", err)
+					continue
+				}
+				select {
+				case out <- signatures:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+func (p *FileProvider) load() ([]*DiscoverySignatures, error) {
+	signatures := make([]*DiscoverySignatures, 0, len(p.files))
+	for _, file := range p.files {
+		loaded, err := loadServiceSignatures(file)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, loaded)
+	}
+	return signatures, nil
+}
+
+// loadServiceSignatures reads discovery rules from a single file.
+func loadServiceSignatures(servicesFile string) (*DiscoverySignatures, error) {
+	var signatures DiscoverySignatures
+	jsonContent, err := ioutil.ReadFile(servicesFile)
+	if err != nil {
+		return &signatures, err
+	}
+
+	if err := json.Unmarshal(jsonContent, &signatures); err != nil {
+		return &signatures, err
+	}
+	return &signatures, nil
+}
+
+// fileWatcher debounces fsnotify events for a fixed set of files and
+// publishes on changed whenever one of them is touched.
+type fileWatcher struct {
+	watcher *fsnotify.Watcher
+	files   map[string]bool
+	changed chan struct{}
+	done    chan struct{}
+}
+
+func newFileWatcher(files []string) (*fileWatcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	watched := make(map[string]bool, len(files))
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			fsWatcher.Close()
+			return nil, err
+		}
+		watched[abs] = true
+		dirs[filepath.Dir(abs)] = true
+	}
+
+	for dir := range dirs {
+		if err := fsWatcher.Add(dir); err != nil {
+			fsWatcher.Close()
+			return nil, err
+		}
+	}
+
+	w := &fileWatcher{
+		watcher: fsWatcher,
+		files:   watched,
+		changed: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+func (w *fileWatcher) run() {
+	defer close(w.changed)
+
+	var (
+		timer   *time.Timer
+		pending <-chan time.Time
+	)
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+
+			abs, err := filepath.Abs(event.Name)
+			if err != nil || !w.files[abs] {
+				continue
+			}
+
+			if timer == nil {
+				timer = time.NewTimer(fileReloadDebounce)
+			} else {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(fileReloadDebounce)
+			}
+			pending = timer.C
+
+		case <-pending:
+			pending = nil
+			select {
+			case w.changed <- struct{}{}:
+			default:
+			}
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("error watching service discovery signature files: %v", err)
+
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *fileWatcher) Close() error {
+	close(w.done)
+	return w.watcher.Close()
+}
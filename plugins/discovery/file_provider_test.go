@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewFileProviderFailsFastOnMissingFile(t *testing.T) {
+	if _, err := NewFileProvider([]string{filepath.Join(t.TempDir(), "does-not-exist.json")}); err == nil {
+		t.Fatalf("expected an error for a missing signatures file")
+	}
+}
+
+func TestNewFileProviderFailsFastOnInvalidJSON(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "signatures.json")
+	if err := os.WriteFile(file, []byte("not json"), 0644); err != nil {
+		t.Fatalf("writing test fixture: %v", err)
+	}
+
+	if _, err := NewFileProvider([]string{file}); err == nil {
+		t.Fatalf("expected an error for invalid JSON")
+	}
+}
+
+func TestFileProviderRunSendsInitialSetImmediately(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "signatures.json")
+	if err := os.WriteFile(file, []byte(`{"Name":"test"}`), 0644); err != nil {
+		t.Fatalf("writing test fixture: %v", err)
+	}
+
+	provider, err := NewFileProvider([]string{file})
+	if err != nil {
+		t.Fatalf("NewFileProvider returned error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	select {
+	case signatures := <-provider.Run(ctx):
+		if len(signatures) != 1 || signatures[0].Name != "test" {
+			t.Fatalf("unexpected initial signatures: %#v", signatures)
+		}
+	default:
+		t.Fatalf("expected the initial signatures to be available without blocking")
+	}
+}
@@ -0,0 +1,190 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/signalfx/neo-agent/plugins/pubsub"
+)
+
+// defaultCacheTTL is how long a signature bundle is kept after its provider
+// last reported it before being evicted. A heartbeating provider that stops
+// publishing (e.g. every poll of the sd endpoint fails) has its signatures
+// aged out instead of left live forever. A signature missing from a
+// provider's latest published set is dropped at once by ingest, not by TTL.
+const defaultCacheTTL = 10 * time.Minute
+
+// cacheKey identifies a signature bundle by the provider that produced it
+// and the bundle's own name, so that two providers can use the same
+// signature name without colliding.
+type cacheKey struct {
+	provider  string
+	signature string
+}
+
+type cacheEntry struct {
+	signatures *DiscoverySignatures
+	lastSeen   time.Time
+}
+
+// Manager fans the output of one or more Providers into a single cache,
+// evicts entries that stop being refreshed, and exposes the merged result
+// to consumers such as filters/service-rules.
+type Manager struct {
+	providers []Provider
+	ttl       time.Duration
+
+	mu    sync.Mutex
+	cache map[cacheKey]cacheEntry
+
+	// heartbeats tracks, per provider name, whether that provider is a
+	// heartbeatingProvider (see evictExpired).
+	heartbeats map[string]bool
+
+	events  *pubsub.Bus[[]*DiscoverySignatures]
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+// NewManager creates a Manager over the given providers using
+// defaultCacheTTL for eviction.
+func NewManager(providers ...Provider) *Manager {
+	heartbeats := make(map[string]bool, len(providers))
+	for _, provider := range providers {
+		if hb, ok := provider.(heartbeatingProvider); ok {
+			heartbeats[provider.Name()] = hb.Heartbeats()
+		}
+	}
+
+	return &Manager{
+		providers:  providers,
+		ttl:        defaultCacheTTL,
+		cache:      make(map[cacheKey]cacheEntry),
+		heartbeats: heartbeats,
+		events:     pubsub.NewBus[[]*DiscoverySignatures](),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// Start runs every provider and begins merging their output into the cache.
+// It returns immediately; call Close to stop.
+func (m *Manager) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+
+	var wg sync.WaitGroup
+	for _, provider := range m.providers {
+		provider := provider
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for signatures := range provider.Run(ctx) {
+				m.ingest(provider.Name(), signatures)
+			}
+		}()
+	}
+
+	go m.evictExpired(ctx)
+
+	go func() {
+		wg.Wait()
+		close(m.stopped)
+	}()
+}
+
+// ingest replaces providerName's entire set of cache entries with signatures
+// and notifies subscribers with the new merged view. Each call is a full
+// snapshot from the provider, so any (providerName, name) key previously
+// cached but absent from this batch is dropped — mirroring the old
+// synchronous full-slice swap, where a signature removed from a file simply
+// stopped being served on the next reload.
+func (m *Manager) ingest(providerName string, signatures []*DiscoverySignatures) {
+	now := time.Now()
+
+	m.mu.Lock()
+	for key := range m.cache {
+		if key.provider == providerName {
+			delete(m.cache, key)
+		}
+	}
+	for _, signature := range signatures {
+		m.cache[cacheKey{provider: providerName, signature: signature.Name}] = cacheEntry{
+			signatures: signature,
+			lastSeen:   now,
+		}
+	}
+	merged := m.mergedLocked()
+	m.mu.Unlock()
+
+	m.events.Publish(merged)
+}
+
+// evictExpired periodically drops cache entries that haven't been refreshed
+// within the TTL, but only for providers that actually heartbeat (see
+// heartbeatingProvider). file and dummy only ever publish once per change,
+// so a quiet file/dummy provider is expected, not stale; evicting its
+// entries anyway would silently empty the ruleset it's still serving.
+func (m *Manager) evictExpired(ctx context.Context) {
+	ticker := time.NewTicker(m.ttl / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			m.mu.Lock()
+			evicted := false
+			for key, entry := range m.cache {
+				if !m.heartbeats[key.provider] {
+					continue
+				}
+				if now.Sub(entry.lastSeen) > m.ttl {
+					delete(m.cache, key)
+					evicted = true
+				}
+			}
+			merged := m.mergedLocked()
+			m.mu.Unlock()
+
+			if evicted {
+				m.events.Publish(merged)
+			}
+		}
+	}
+}
+
+func (m *Manager) mergedLocked() []*DiscoverySignatures {
+	merged := make([]*DiscoverySignatures, 0, len(m.cache))
+	for _, entry := range m.cache {
+		merged = append(merged, entry.signatures)
+	}
+	return merged
+}
+
+// Signatures returns the current merged view across all providers.
+func (m *Manager) Signatures() []*DiscoverySignatures {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mergedLocked()
+}
+
+// Subscribe registers a new subscriber that receives the merged signature
+// set every time it changes. Values published before Subscribe is called are
+// not replayed. See pubsub.Bus.Subscribe for buffer semantics.
+func (m *Manager) Subscribe(buffer int) (<-chan []*DiscoverySignatures, func()) {
+	return m.events.Subscribe(buffer)
+}
+
+// Close stops every provider and the eviction loop, and waits for every
+// provider's Run channel to close. It does not wait for the eviction loop to
+// return.
+func (m *Manager) Close() error {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	<-m.stopped
+	return nil
+}
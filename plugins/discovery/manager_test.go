@@ -0,0 +1,100 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeProvider lets a test push signature batches on demand and control when
+// Run's channel closes.
+type fakeProvider struct {
+	name       string
+	heartbeats bool
+	sends      chan []*DiscoverySignatures
+}
+
+func newFakeProvider(name string, heartbeats bool) *fakeProvider {
+	return &fakeProvider{name: name, heartbeats: heartbeats, sends: make(chan []*DiscoverySignatures)}
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Heartbeats() bool { return p.heartbeats }
+
+func (p *fakeProvider) Run(ctx context.Context) <-chan []*DiscoverySignatures {
+	out := make(chan []*DiscoverySignatures)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case batch, ok := <-p.sends:
+				if !ok {
+					return
+				}
+				select {
+				case out <- batch:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+func TestManagerIngestPrunesStaleKeys(t *testing.T) {
+	provider := newFakeProvider("file", false)
+	m := NewManager(provider)
+	m.Start()
+	defer m.Close()
+
+	provider.sends <- []*DiscoverySignatures{
+		{Name: "a"}, {Name: "b"},
+	}
+	waitForSignatureCount(t, m, 2)
+
+	provider.sends <- []*DiscoverySignatures{
+		{Name: "a"},
+	}
+	waitForSignatureCount(t, m, 1)
+
+	merged := m.Signatures()
+	if len(merged) != 1 || merged[0].Name != "a" {
+		t.Fatalf("expected only signature %q to remain, got %#v", "a", merged)
+	}
+}
+
+func TestManagerTTLEvictionScopedToHeartbeatingProviders(t *testing.T) {
+	fileProvider := newFakeProvider("file", false)
+	m := NewManager(fileProvider)
+	m.ttl = 10 * time.Millisecond
+	m.Start()
+	defer m.Close()
+
+	fileProvider.sends <- []*DiscoverySignatures{{Name: "a"}}
+	waitForSignatureCount(t, m, 1)
+
+	time.Sleep(5 * m.ttl)
+
+	if merged := m.Signatures(); len(merged) != 1 {
+		t.Fatalf("expected non-heartbeating file provider's signature to survive TTL, got %#v", merged)
+	}
+}
+
+func waitForSignatureCount(t *testing.T, m *Manager, n int) {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		if len(m.Signatures()) == n {
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for %d signatures, have %#v", n, m.Signatures())
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
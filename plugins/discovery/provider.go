@@ -0,0 +1,27 @@
+package discovery
+
+import "context"
+
+// Provider produces DiscoverySignatures bundles over time. Implementations
+// should send the full current set of signatures they own on the returned
+// channel whenever it changes (including once, immediately, with the
+// initial set) and close the channel once ctx is done.
+type Provider interface {
+	// Name identifies the provider for cache keys and logging, e.g. "file"
+	// or "sd".
+	Name() string
+	// Run starts producing signature bundles and returns a channel of
+	// updates. The provider owns the channel and must close it when ctx is
+	// canceled.
+	Run(ctx context.Context) <-chan []*DiscoverySignatures
+}
+
+// heartbeatingProvider is implemented by providers that keep re-publishing
+// their current signatures on a cadence even when unchanged (e.g. a poller
+// that re-sends on every tick). Manager only TTL-evicts cache entries that
+// came from such a provider, since a provider that only ever publishes once
+// on change (file, dummy) going quiet is normal, not a sign that its
+// signatures are stale.
+type heartbeatingProvider interface {
+	Heartbeats() bool
+}
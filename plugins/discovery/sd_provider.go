@@ -0,0 +1,128 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// defaultSDPollInterval is how often the sd provider polls its endpoint when
+// the config doesn't specify one.
+const defaultSDPollInterval = time.Minute
+
+// SDProvider pulls signature bundles from an HTTP(S) endpoint on an
+// interval. It sends ETag's If-None-Match on every poll after the first so
+// that a 304 Not Modified response costs nothing beyond the round trip; on a
+// 304 it still re-publishes the last known bundle so Manager sees this
+// provider as alive and doesn't TTL-evict it while the endpoint is healthy.
+type SDProvider struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+}
+
+// NewSDProvider creates a provider that polls url every interval (or
+// defaultSDPollInterval if interval is zero) for a JSON array of
+// DiscoverySignatures.
+func NewSDProvider(url string, interval time.Duration) *SDProvider {
+	if interval <= 0 {
+		interval = defaultSDPollInterval
+	}
+	return &SDProvider{url: url, interval: interval, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Name implements Provider.
+func (p *SDProvider) Name() string {
+	return "sd"
+}
+
+// Heartbeats implements heartbeatingProvider: a healthy sd endpoint is
+// re-polled (and re-published) on every interval tick, so Manager is safe to
+// TTL-evict its signatures if those polls stop succeeding.
+func (p *SDProvider) Heartbeats() bool {
+	return true
+}
+
+// Run implements Provider.
+func (p *SDProvider) Run(ctx context.Context) <-chan []*DiscoverySignatures {
+	out := make(chan []*DiscoverySignatures, 1)
+
+	go func() {
+		defer close(out)
+
+		var (
+			etag string
+			last []*DiscoverySignatures
+		)
+		poll := func() {
+			signatures, newETag, changed, err := p.fetch(ctx, etag)
+			if err != nil {
+				log.Printf("error polling sd discovery endpoint %s: %v", p.url, err)
+				return
+			}
+			if changed {
+				etag = newETag
+				last = signatures
+			} else if last == nil {
+				// First poll came back 304 somehow (e.g. a pre-warmed
+				// cache); nothing to heartbeat with yet.
+				return
+			}
+			select {
+			case out <- last:
+			case <-ctx.Done():
+			}
+		}
+
+		poll()
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				poll()
+			}
+		}
+	}()
+
+	return out
+}
+
+// fetch requests the signatures bundle, sending etag as If-None-Match if
+// non-empty. changed is false when the server responds 304 Not Modified.
+func (p *SDProvider) fetch(ctx context.Context, etag string) (signatures []*DiscoverySignatures, newETag string, changed bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", false, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, p.url)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&signatures); err != nil {
+		return nil, "", false, err
+	}
+
+	return signatures, resp.Header.Get("ETag"), true, nil
+}
@@ -0,0 +1,27 @@
+// Package discovery provides a pluggable subsystem for producing the
+// DiscoverySignatures that filters/service-rules matches against. Signatures
+// can come from the local filesystem, a static in-memory set (useful for
+// tests and CI), or a remote HTTP(S) endpoint, fanned in by a Manager.
+package discovery
+
+// DiscoveryRuleset that names a set of service discovery rules
+type DiscoveryRuleset struct {
+	Name string
+	Type string
+	// Rules are criteria for service identification
+	Rules []struct {
+		Comparator string
+		Path       string
+		Value      interface{}
+	}
+	// Rego is an alternative to Rules: a Rego policy that is evaluated
+	// against the same service instance instead of the comparator DSL.
+	// When set, Rules is ignored.
+	Rego string
+}
+
+// DiscoverySignatures with name
+type DiscoverySignatures struct {
+	Name       string
+	Signatures []DiscoveryRuleset
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/signalfx/neo-agent/services"
+)
+
+// matcher tests a single string value: a plain string (exact match), a
+// glob-style pattern (prefixed "glob:"), or a regular expression (prefixed
+// "regex:"). Plain strings without a recognized prefix are matched literally
+// — a container name containing "*" or "[" is not silently treated as a
+// pattern; use the "glob:" prefix when pattern matching is actually wanted.
+type matcher struct {
+	raw  string
+	glob bool
+	re   *regexp.Regexp
+}
+
+func newMatcher(raw string) (*matcher, error) {
+	m := &matcher{raw: raw}
+
+	switch {
+	case strings.HasPrefix(raw, "regex:"):
+		re, err := regexp.Compile(strings.TrimPrefix(raw, "regex:"))
+		if err != nil {
+			return nil, err
+		}
+		m.re = re
+	case strings.HasPrefix(raw, "glob:"):
+		m.raw = strings.TrimPrefix(raw, "glob:")
+		m.glob = true
+	}
+
+	// Validate glob patterns at construction time so a malformed pattern
+	// (e.g. an unterminated "[") fails agent startup instead of silently
+	// never matching at discovery time.
+	if m.glob {
+		if _, err := path.Match(m.raw, ""); err != nil {
+			return nil, fmt.Errorf("invalid blacklist pattern %q: %v", raw, err)
+		}
+	}
+
+	return m, nil
+}
+
+func (m *matcher) match(value string) bool {
+	switch {
+	case m.re != nil:
+		return m.re.MatchString(value)
+	case m.glob:
+		ok, err := path.Match(m.raw, value)
+		return err == nil && ok
+	default:
+		return m.raw == value
+	}
+}
+
+// blacklist holds the compiled exclusion rules that are checked before any
+// signature is evaluated. Instances matching any rule here are dropped
+// without ever reaching the comparator or rego engines.
+type blacklist struct {
+	images         []*matcher
+	containerNames []*matcher
+	labels         map[string][]*matcher
+	excludePorts   map[string]bool
+}
+
+// blacklistConfig is the raw config shape for the blacklist section of a
+// filters/service-rules plugin config.
+type blacklistConfig struct {
+	BlacklistedImages         []string
+	BlacklistedContainerNames []string
+	BlacklistedLabels         map[string][]string
+	ExcludePorts              []int
+}
+
+func newBlacklist(cfg blacklistConfig) (*blacklist, error) {
+	b := &blacklist{
+		labels:       make(map[string][]*matcher),
+		excludePorts: make(map[string]bool, len(cfg.ExcludePorts)),
+	}
+
+	for _, raw := range cfg.BlacklistedImages {
+		m, err := newMatcher(raw)
+		if err != nil {
+			return nil, err
+		}
+		b.images = append(b.images, m)
+	}
+
+	for _, raw := range cfg.BlacklistedContainerNames {
+		m, err := newMatcher(raw)
+		if err != nil {
+			return nil, err
+		}
+		b.containerNames = append(b.containerNames, m)
+	}
+
+	for label, patterns := range cfg.BlacklistedLabels {
+		for _, raw := range patterns {
+			m, err := newMatcher(raw)
+			if err != nil {
+				return nil, err
+			}
+			b.labels[label] = append(b.labels[label], m)
+		}
+	}
+
+	for _, port := range cfg.ExcludePorts {
+		b.excludePorts[strconv.Itoa(port)] = true
+	}
+
+	return b, nil
+}
+
+// matches reports whether si should be dropped before signature evaluation.
+func (b *blacklist) matches(si *services.Instance) bool {
+	if b == nil {
+		return false
+	}
+
+	for _, m := range b.images {
+		if m.match(si.Container.Image) {
+			return true
+		}
+	}
+
+	for _, name := range si.Container.Names {
+		for _, m := range b.containerNames {
+			if m.match(name) {
+				return true
+			}
+		}
+	}
+
+	for label, value := range si.Container.Labels {
+		for _, m := range b.labels[label] {
+			if m.match(value) {
+				return true
+			}
+		}
+	}
+
+	publicPort := strconv.FormatUint(uint64(si.Port.PublicPort), 10)
+	privatePort := strconv.FormatUint(uint64(si.Port.PrivatePort), 10)
+	if b.excludePorts[publicPort] || b.excludePorts[privatePort] {
+		return true
+	}
+
+	return false
+}
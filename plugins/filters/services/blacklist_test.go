@@ -0,0 +1,51 @@
+package services
+
+import "testing"
+
+func TestMatcherPlainStringIsExactMatch(t *testing.T) {
+	m, err := newMatcher("redis*")
+	if err != nil {
+		t.Fatalf("newMatcher returned error: %v", err)
+	}
+
+	if m.match("redis-primary") {
+		t.Fatalf("plain string %q should not glob-match %q", "redis*", "redis-primary")
+	}
+	if !m.match("redis*") {
+		t.Fatalf("plain string %q should exact-match itself", "redis*")
+	}
+}
+
+func TestMatcherGlobPrefixMatchesPattern(t *testing.T) {
+	m, err := newMatcher("glob:redis*")
+	if err != nil {
+		t.Fatalf("newMatcher returned error: %v", err)
+	}
+
+	if !m.match("redis-primary") {
+		t.Fatalf("glob pattern %q should match %q", "glob:redis*", "redis-primary")
+	}
+	if m.match("memcached") {
+		t.Fatalf("glob pattern %q should not match %q", "glob:redis*", "memcached")
+	}
+}
+
+func TestMatcherGlobPrefixRejectsInvalidPattern(t *testing.T) {
+	if _, err := newMatcher("glob:["); err == nil {
+		t.Fatalf("expected an error for an invalid glob pattern")
+	}
+}
+
+func TestMatcherRegexPrefixMatchesPattern(t *testing.T) {
+	m, err := newMatcher("regex:^redis-\\d+$")
+	if err != nil {
+		t.Fatalf("newMatcher returned error: %v", err)
+	}
+
+	if !m.match("redis-1") {
+		t.Fatalf("regex pattern should match %q", "redis-1")
+	}
+	if m.match("redis-primary") {
+		t.Fatalf("regex pattern should not match %q", "redis-primary")
+	}
+}
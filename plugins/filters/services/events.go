@@ -0,0 +1,100 @@
+package services
+
+import (
+	"time"
+
+	"github.com/signalfx/neo-agent/plugins/pubsub"
+	"github.com/signalfx/neo-agent/services"
+)
+
+// DiscoveryEventType identifies why a DiscoveryEvent was published.
+type DiscoveryEventType int
+
+const (
+	// Added is published the first time Map matches an instance to a
+	// signature.
+	Added DiscoveryEventType = iota
+	// Updated is published when a previously seen instance's matched
+	// signature or ruleset changes.
+	Updated
+	// Removed is published when an instance matched by the previous call to
+	// Map is not matched by the current one.
+	Removed
+	// Matched is published whenever an instance satisfies a signature.
+	Matched
+	// Unmatched is published whenever an instance satisfies no signature.
+	Unmatched
+)
+
+// DiscoveryEvent describes a single change observed by RuleFilter.Map.
+type DiscoveryEvent struct {
+	Type             DiscoveryEventType
+	Instance         services.Instance
+	MatchedSignature string
+	MatchedRuleset   string
+	Timestamp        time.Time
+}
+
+// instanceState is the last known state of a discovered instance, tracked by
+// instance ID so that Map can diff successive calls.
+type instanceState struct {
+	instance  services.Instance
+	signature string
+	ruleset   string
+}
+
+// matchedInstance pairs a matched service instance with the signature and
+// ruleset that matched it, so diffState can publish Added/Updated events
+// that name the actual rule responsible instead of guessing from the
+// instance's resulting service type.
+type matchedInstance struct {
+	instance  services.Instance
+	signature string
+	ruleset   string
+}
+
+// diffState compares the just-computed set of matched instances against the
+// previously seen set (tracked by instance ID), publishes Added/Updated/
+// Removed events for the difference, and returns the new state to store for
+// next time.
+func (filter *RuleFilter) diffState(matched []matchedInstance) map[string]instanceState {
+	now := time.Now()
+	next := make(map[string]instanceState, len(matched))
+
+	filter.prevMu.Lock()
+	prev := filter.prevState
+	filter.prevMu.Unlock()
+
+	for _, m := range matched {
+		next[m.instance.ID] = instanceState{instance: m.instance, signature: m.signature, ruleset: m.ruleset}
+
+		if old, ok := prev[m.instance.ID]; !ok {
+			filter.events.Publish(DiscoveryEvent{
+				Type: Added, Instance: m.instance, MatchedSignature: m.signature,
+				MatchedRuleset: m.ruleset, Timestamp: now,
+			})
+		} else if old.signature != m.signature || old.ruleset != m.ruleset {
+			filter.events.Publish(DiscoveryEvent{
+				Type: Updated, Instance: m.instance, MatchedSignature: m.signature,
+				MatchedRuleset: m.ruleset, Timestamp: now,
+			})
+		}
+	}
+
+	for id, old := range prev {
+		if _, ok := next[id]; !ok {
+			filter.events.Publish(DiscoveryEvent{
+				Type: Removed, Instance: old.instance, MatchedSignature: old.signature,
+				MatchedRuleset: old.ruleset, Timestamp: now,
+			})
+		}
+	}
+
+	return next
+}
+
+// Subscribe registers a new subscriber to the filter's discovery events. See
+// pubsub.Bus.Subscribe for buffer semantics.
+func (filter *RuleFilter) Subscribe(buffer int) (<-chan DiscoveryEvent, func()) {
+	return filter.events.Subscribe(buffer)
+}
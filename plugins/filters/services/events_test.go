@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/signalfx/neo-agent/plugins/pubsub"
+	"github.com/signalfx/neo-agent/services"
+)
+
+func TestDiffStatePublishesAddedUpdatedRemoved(t *testing.T) {
+	filter := &RuleFilter{events: pubsub.NewBus[DiscoveryEvent]()}
+	updates, unsubscribe := filter.events.Subscribe(8)
+	defer unsubscribe()
+
+	a := services.Instance{ID: "a"}
+	b := services.Instance{ID: "b"}
+
+	filter.prevState = filter.diffState([]matchedInstance{
+		{instance: a, signature: "sig1", ruleset: "rule1"},
+		{instance: b, signature: "sig1", ruleset: "rule1"},
+	})
+
+	wantAdded := map[string]bool{"a": true, "b": true}
+	for i := 0; i < 2; i++ {
+		event := <-updates
+		if event.Type != Added {
+			t.Fatalf("expected Added event, got %v", event.Type)
+		}
+		delete(wantAdded, event.Instance.ID)
+	}
+	if len(wantAdded) != 0 {
+		t.Fatalf("missing Added events for %v", wantAdded)
+	}
+
+	filter.prevState = filter.diffState([]matchedInstance{
+		{instance: a, signature: "sig2", ruleset: "rule2"},
+	})
+
+	sawUpdated, sawRemoved := false, false
+	for i := 0; i < 2; i++ {
+		event := <-updates
+		switch {
+		case event.Type == Updated && event.Instance.ID == "a":
+			sawUpdated = true
+		case event.Type == Removed && event.Instance.ID == "b":
+			sawRemoved = true
+		default:
+			t.Fatalf("unexpected event: %#v", event)
+		}
+	}
+	if !sawUpdated {
+		t.Fatalf("expected an Updated event for instance %q", "a")
+	}
+	if !sawRemoved {
+		t.Fatalf("expected a Removed event for instance %q", "b")
+	}
+}
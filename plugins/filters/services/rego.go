@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/signalfx/neo-agent/services"
+)
+
+// regoResultQuery is the rule that every Rego signature policy must define.
+// It mirrors the structure used by netdata's discovery policies: a single
+// boolean decision that a service instance matches the signature.
+const regoResultQuery = "data.signalfx.discovery.match"
+
+// inputSchema describes the shape of the document passed to each policy via
+// rego.EvalInput, so that WithSchemas type checking can catch typos in
+// policies (e.g. container.lables) at compile time instead of at the first
+// discovery pass.
+const inputSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"properties": {
+		"container": {
+			"type": "object",
+			"properties": {
+				"id":      {"type": "string"},
+				"name":    {"type": "string"},
+				"image":   {"type": "string"},
+				"pod":     {"type": "string"},
+				"command": {"type": "string"},
+				"state":   {"type": "string"},
+				"labels":  {"type": "object"}
+			}
+		},
+		"network": {
+			"type": "object",
+			"properties": {
+				"ip":          {"type": "string"},
+				"type":        {"type": "string"},
+				"publicPort":  {"type": "integer"},
+				"privatePort": {"type": "integer"},
+				"labels":      {"type": "object"}
+			}
+		}
+	}
+}`
+
+// regoEngine compiles and caches a prepared query for every signature+ruleset
+// pair that uses a Rego policy instead of the comparator DSL.
+type regoEngine struct {
+	queries map[string]rego.PreparedEvalQuery
+}
+
+// newRegoEngine compiles all Rego policies found in signatures up front so
+// that a bad policy fails the agent at startup rather than at first
+// discovery.
+func newRegoEngine(signatures []*DiscoverySignatures) (*regoEngine, error) {
+	engine := &regoEngine{queries: make(map[string]rego.PreparedEvalQuery)}
+
+	schemas := inputSchemaSet()
+
+	for _, signature := range signatures {
+		for _, ruleset := range signature.Signatures {
+			if ruleset.Rego == "" {
+				continue
+			}
+
+			key := regoKey(signature.Name, ruleset.Name)
+			prepared, err := rego.New(
+				rego.Query(regoResultQuery),
+				rego.Module(key+".rego", ruleset.Rego),
+				rego.Schemas(schemas),
+			).PrepareForEval(context.Background())
+			if err != nil {
+				return nil, fmt.Errorf("compiling rego policy for %s: %v", key, err)
+			}
+
+			engine.queries[key] = prepared
+		}
+	}
+
+	return engine, nil
+}
+
+// eval runs the prepared query for signature/ruleset against si and reports
+// whether the policy's match rule evaluated to true.
+func (e *regoEngine) eval(ctx context.Context, signature, ruleset string, si *services.Instance) (bool, error) {
+	key := regoKey(signature, ruleset)
+	prepared, ok := e.queries[key]
+	if !ok {
+		return false, fmt.Errorf("no compiled rego policy for %s", key)
+	}
+
+	results, err := prepared.Eval(ctx, rego.EvalInput(regoInput(si)))
+	if err != nil {
+		return false, err
+	}
+
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		return false, nil
+	}
+
+	matched, _ := results[0].Expressions[0].Value.(bool)
+	return matched, nil
+}
+
+// regoInput builds the structured input document for a service instance,
+// using nested maps instead of the flat ContainerLabel-<key> strings used by
+// the comparator DSL so that policies can range over container.labels.
+func regoInput(si *services.Instance) map[string]interface{} {
+	return map[string]interface{}{
+		"container": map[string]interface{}{
+			"id":      si.Container.ID,
+			"name":    si.Container.Names[0],
+			"image":   si.Container.Image,
+			"pod":     si.Container.Pod,
+			"command": si.Container.Command,
+			"state":   si.Container.State,
+			"labels":  si.Container.Labels,
+		},
+		"network": map[string]interface{}{
+			"ip":          si.Port.IP,
+			"type":        si.Port.Type,
+			"publicPort":  si.Port.PublicPort,
+			"privatePort": si.Port.PrivatePort,
+			"labels":      si.Port.Labels,
+		},
+	}
+}
+
+func regoKey(signature, ruleset string) string {
+	return signature + "/" + ruleset
+}
+
+// mustParseSchema parses the static inputSchema constant. It panics on
+// failure since the schema is compiled into the binary, not user-supplied.
+func mustParseSchema() interface{} {
+	var schema interface{}
+	if err := json.Unmarshal([]byte(inputSchema), &schema); err != nil {
+		panic("services: invalid embedded input schema: " + err.Error())
+	}
+	return schema
+}
+
+// inputSchemaRef is the document path WithSchemas type checking associates
+// the input schema with: the root of rego.EvalInput.
+var inputSchemaRef = ast.MustParseRef("input")
+
+// inputSchemaSet builds the *ast.SchemaSet that rego.Schemas expects,
+// binding the embedded inputSchema to the input document.
+func inputSchemaSet() *ast.SchemaSet {
+	schemas := ast.NewSchemaSet()
+	schemas.Put(inputSchemaRef, mustParseSchema())
+	return schemas
+}
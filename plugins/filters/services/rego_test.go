@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/signalfx/neo-agent/services"
+)
+
+const testRegoPolicy = `
+package signalfx.discovery
+
+default match = false
+
+match {
+	input.container.image == "redis:latest"
+}
+`
+
+func TestNewRegoEngineCompilesAndMatches(t *testing.T) {
+	signatures := []*DiscoverySignatures{
+		{
+			Name: "test-signatures",
+			Signatures: []DiscoveryRuleset{
+				{Name: "redis", Type: "redis", Rego: testRegoPolicy},
+			},
+		},
+	}
+
+	engine, err := newRegoEngine(signatures)
+	if err != nil {
+		t.Fatalf("newRegoEngine returned error: %v", err)
+	}
+
+	si := &services.Instance{Container: services.Container{Image: "redis:latest", Names: []string{"redis"}}}
+	matched, err := engine.eval(context.Background(), "test-signatures", "redis", si)
+	if err != nil {
+		t.Fatalf("eval returned error: %v", err)
+	}
+	if !matched {
+		t.Errorf("expected matching image to satisfy policy")
+	}
+
+	si.Container.Image = "not-redis"
+	matched, err = engine.eval(context.Background(), "test-signatures", "redis", si)
+	if err != nil {
+		t.Fatalf("eval returned error: %v", err)
+	}
+	if matched {
+		t.Errorf("expected non-matching image to not satisfy policy")
+	}
+}
+
+func TestNewRegoEngineRejectsInvalidPolicy(t *testing.T) {
+	signatures := []*DiscoverySignatures{
+		{
+			Name: "bad-signatures",
+			Signatures: []DiscoveryRuleset{
+				{Name: "broken", Type: "broken", Rego: "not a valid rego policy {{{"},
+			},
+		},
+	}
+
+	if _, err := newRegoEngine(signatures); err == nil {
+		t.Fatalf("expected an error compiling an invalid rego policy")
+	}
+}
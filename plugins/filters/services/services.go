@@ -1,40 +1,53 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
+	"fmt"
 	"log"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	ruler "github.com/hopkinsth/go-ruler"
 	"github.com/signalfx/neo-agent/plugins"
+	"github.com/signalfx/neo-agent/plugins/discovery"
+	"github.com/signalfx/neo-agent/plugins/pubsub"
 	"github.com/signalfx/neo-agent/services"
 	"github.com/spf13/viper"
 )
 
-// DiscoveryRuleset that names a set of service discovery rules
-type DiscoveryRuleset struct {
-	Name string
-	Type string
-	// Rules are criteria for service identification
-	Rules []struct {
-		Comparator string
-		Path       string
-		Value      interface{}
-	}
-}
-
-// DiscoverySignatures with name
-type DiscoverySignatures struct {
-	Name       string
-	Signatures []DiscoveryRuleset
+// DiscoveryRuleset and DiscoverySignatures are aliases of the discovery
+// package's types, kept here so existing signature files and callers that
+// reference services.DiscoveryRuleset/services.DiscoverySignatures keep
+// working now that the discovery.Manager subsystem owns them.
+type DiscoveryRuleset = discovery.DiscoveryRuleset
+type DiscoverySignatures = discovery.DiscoverySignatures
+
+// ruleSnapshot is the atomically-swapped view of the currently active
+// signatures and their compiled Rego queries. Keeping both together means a
+// reload can never be observed with stale rego queries for a new ruleset (or
+// vice versa).
+type ruleSnapshot struct {
+	serviceRules []*DiscoverySignatures
+	regoEngine   *regoEngine
 }
 
 // RuleFilter filters instances based on rules
 type RuleFilter struct {
 	plugins.Plugin
-	serviceRules []*DiscoverySignatures
+	manager *discovery.Manager
+	rules   atomic.Pointer[ruleSnapshot]
+
+	unsubscribe func()
+
+	events    *pubsub.Bus[DiscoveryEvent]
+	prevMu    sync.Mutex
+	prevState map[string]instanceState
+
+	blacklist *blacklist
 }
 
 func init() {
@@ -43,49 +56,119 @@ func init() {
 
 // NewRuleFilter creates a new instance
 func NewRuleFilter(name string, config *viper.Viper) (plugins.IPlugin, error) {
-	var (
-		signatures    []*DiscoverySignatures
-		servicesFiles []string
-		err           error
-	)
-
 	plugin, err := plugins.NewPlugin(name, config)
 	if err != nil {
 		return nil, err
 	}
 
-	if servicesFiles = plugin.Config.GetStringSlice("servicesfiles"); len(servicesFiles) == 0 {
-		return nil, errors.New("servicesFiles configuration value missing")
+	providers, err := discoveryProviders(plugin.Config)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, servicesFile := range servicesFiles {
-		log.Printf("loading service discovery signatures from %s", servicesFile)
-		loaded, err := loadServiceSignatures(servicesFile)
+	manager := discovery.NewManager(providers...)
+
+	blacklist, err := newBlacklist(blacklistConfig{
+		BlacklistedImages:         plugin.Config.GetStringSlice("blacklistedimages"),
+		BlacklistedContainerNames: plugin.Config.GetStringSlice("blacklistedcontainernames"),
+		BlacklistedLabels:         plugin.Config.GetStringMapStringSlice("blacklistedlabels"),
+		ExcludePorts:              plugin.Config.GetIntSlice("excludeports"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &RuleFilter{Plugin: plugin, manager: manager, events: pubsub.NewBus[DiscoveryEvent](), blacklist: blacklist}
+
+	updates, unsubscribe := manager.Subscribe(0)
+	filter.unsubscribe = unsubscribe
+
+	manager.Start()
+
+	// Block for the manager's first merged view so NewRuleFilter returns
+	// with a usable ruleset, the same as the old synchronous load did.
+	if err := filter.applySignatures(<-updates); err != nil {
+		manager.Close()
+		return nil, err
+	}
+
+	go func() {
+		for signatures := range updates {
+			if err := filter.applySignatures(signatures); err != nil {
+				log.Printf("error applying reloaded service discovery signatures: %v", err)
+			}
+		}
+	}()
+
+	return filter, nil
+}
+
+// discoveryProviders builds the set of discovery.Providers configured for
+// this plugin instance. "provider" selects the primary source of signatures
+// ("file", the default, or "dummy" for a static in-memory set useful in
+// tests/CI); "sdURL" additionally layers a central sd provider on top of
+// whichever primary source is configured, so a fleet can mix locally-defined
+// signatures with ones pushed from a central endpoint.
+func discoveryProviders(config *viper.Viper) ([]discovery.Provider, error) {
+	var providers []discovery.Provider
+
+	switch providerType := config.GetString("provider"); providerType {
+	case "", "file":
+		servicesFiles := config.GetStringSlice("servicesfiles")
+		if len(servicesFiles) == 0 {
+			return nil, errors.New("servicesFiles configuration value missing")
+		}
+		fileProvider, err := discovery.NewFileProvider(servicesFiles)
 		if err != nil {
 			return nil, err
 		}
-		signatures = append(signatures, loaded)
+		providers = append(providers, fileProvider)
+
+	case "dummy":
+		raw := config.GetString("dummysignatures")
+		if raw == "" {
+			return nil, errors.New("dummySignatures configuration value missing for dummy provider")
+		}
+		var signatures []*DiscoverySignatures
+		if err := json.Unmarshal([]byte(raw), &signatures); err != nil {
+			return nil, fmt.Errorf("parsing dummySignatures: %v", err)
+		}
+		providers = append(providers, discovery.NewDummyProvider(signatures))
+
+	default:
+		return nil, fmt.Errorf("unknown discovery provider %q", providerType)
+	}
+
+	if sdURL := config.GetString("sdurl"); sdURL != "" {
+		providers = append(providers, discovery.NewSDProvider(sdURL, config.GetDuration("sdinterval")))
 	}
 
-	return &RuleFilter{plugin, signatures}, nil
+	return providers, nil
 }
 
-// loadServiceSignatures reads discovery rules from file
-func loadServiceSignatures(servicesFile string) (*DiscoverySignatures, error) {
-	var signatures DiscoverySignatures
-	jsonContent, err := ioutil.ReadFile(servicesFile)
+// applySignatures compiles the rego policies for signatures and, on
+// success, atomically swaps them in as the active ruleset.
+func (filter *RuleFilter) applySignatures(signatures []*DiscoverySignatures) error {
+	regoEngine, err := newRegoEngine(signatures)
 	if err != nil {
-		return &signatures, err
+		return err
 	}
 
-	if err := json.Unmarshal(jsonContent, &signatures); err != nil {
-		return &signatures, err
-	}
-	return &signatures, nil
+	filter.rules.Store(&ruleSnapshot{serviceRules: signatures, regoEngine: regoEngine})
+	return nil
+}
+
+// Close stops the underlying discovery manager and its providers.
+func (filter *RuleFilter) Close() error {
+	filter.unsubscribe()
+	return filter.manager.Close()
 }
 
 // Matches if service instance satisfies rules
-func matches(si *services.Instance, ruleset DiscoveryRuleset) (bool, error) {
+func matches(si *services.Instance, ruleset DiscoveryRuleset, signature string, policies *regoEngine) (bool, error) {
+	if ruleset.Rego != "" {
+		return policies.eval(context.Background(), signature, ruleset.Name, si)
+	}
 	jsonRules, err := json.Marshal(ruleset.Rules)
 	if err != nil {
 		return false, err
@@ -123,13 +206,21 @@ func matches(si *services.Instance, ruleset DiscoveryRuleset) (bool, error) {
 // Map matches discovered service instances to a plugin type.
 func (filter *RuleFilter) Map(sis services.Instances) (services.Instances, error) {
 	applicableServices := make(services.Instances, 0, len(sis))
+	matchedInstances := make([]matchedInstance, 0, len(sis))
+	snapshot := filter.rules.Load()
+	now := time.Now()
 
 	// Find the first rule that matches each service instance.
 OUTER:
 	for i := range sis {
-		for _, signature := range filter.serviceRules {
+		if filter.blacklist.matches(&sis[i]) {
+			log.Printf("debug: dropping blacklisted instance %s before rule matching", sis[i].ID)
+			continue OUTER
+		}
+
+		for _, signature := range snapshot.serviceRules {
 			for _, ruleset := range signature.Signatures {
-				matches, err := matches(&sis[i], ruleset)
+				matches, err := matches(&sis[i], ruleset, signature.Name, snapshot.regoEngine)
 				if err != nil {
 					return nil, err
 				}
@@ -139,12 +230,25 @@ OUTER:
 					// FIXME: what if it's not a known service type?
 					sis[i].Service.Type = services.ServiceType(ruleset.Type)
 					applicableServices = append(applicableServices, sis[i])
+					matchedInstances = append(matchedInstances, matchedInstance{
+						instance: sis[i], signature: signature.Name, ruleset: ruleset.Name,
+					})
+					filter.events.Publish(DiscoveryEvent{
+						Type: Matched, Instance: sis[i], MatchedSignature: signature.Name,
+						MatchedRuleset: ruleset.Name, Timestamp: now,
+					})
 					// Rule found, continue to next service instance.
 					continue OUTER
 				}
 			}
 		}
+		filter.events.Publish(DiscoveryEvent{Type: Unmatched, Instance: sis[i], Timestamp: now})
 	}
 
+	next := filter.diffState(matchedInstances)
+	filter.prevMu.Lock()
+	filter.prevState = next
+	filter.prevMu.Unlock()
+
 	return applicableServices, nil
 }
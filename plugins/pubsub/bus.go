@@ -0,0 +1,78 @@
+// Package pubsub provides a small generic broadcast primitive shared by the
+// discovery and filters/service-rules subsystems: publish a value to every
+// current subscriber, dropping it for any subscriber that isn't keeping up
+// rather than blocking the publisher.
+package pubsub
+
+import (
+	"log"
+	"sync"
+)
+
+// DefaultBuffer is the subscriber channel capacity used when Subscribe is
+// called with buffer <= 0.
+const DefaultBuffer = 64
+
+// Bus fans values of type T out to subscribers.
+type Bus[T any] struct {
+	mu          sync.Mutex
+	subscribers map[int]chan T
+	nextID      int
+	dropped     uint64
+}
+
+// NewBus creates an empty Bus.
+func NewBus[T any]() *Bus[T] {
+	return &Bus[T]{subscribers: make(map[int]chan T)}
+}
+
+// Subscribe registers a new subscriber and returns its channel along with an
+// unsubscribe closure. buffer controls the channel's capacity; pass 0 to use
+// DefaultBuffer.
+func (b *Bus[T]) Subscribe(buffer int) (<-chan T, func()) {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	ch := make(chan T, buffer)
+	b.subscribers[id] = ch
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if ch, ok := b.subscribers[id]; ok {
+			delete(b.subscribers, id)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish delivers value to every subscriber, dropping it (and counting the
+// drop) for any subscriber whose channel is full.
+func (b *Bus[T]) Publish(value T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- value:
+		default:
+			b.dropped++
+			log.Printf("pubsub: dropped value for slow subscriber (total dropped: %d)", b.dropped)
+		}
+	}
+}
+
+// Dropped reports how many values have been dropped for slow subscribers
+// since startup.
+func (b *Bus[T]) Dropped() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}